@@ -0,0 +1,111 @@
+package logging
+
+import (
+   "fmt"
+   "os"
+   _ "unsafe" // for go:linkname
+)
+
+// runtime_procPin pins the calling goroutine to its current P and returns
+// the P's id, the same mechanism sync.Pool uses to pick a per-P slot.
+// Unlike a fresh fastrand() on every call, a goroutine's P (and therefore
+// its pickWorker result) is sticky across most of its calls, since the
+// scheduler doesn't migrate a running goroutine to another P without
+// cause. That stickiness is the point: it gives repeated enqueues from the
+// same goroutine a good chance of landing on the same worker channel,
+// reducing cross-CPU cache-line bouncing on that channel's internal lock.
+//
+//go:linkname runtime_procPin runtime.procPin
+func runtime_procPin() int
+
+//go:linkname runtime_procUnpin runtime.procUnpin
+func runtime_procUnpin()
+
+func pickWorker(numWorkers int) int {
+   pid := runtime_procPin()
+   runtime_procUnpin()
+   return pid % numWorkers
+}
+
+// OverflowPolicy controls what happens when a worker's channel is full.
+type OverflowPolicy int32
+
+const (
+   // PolicyDrop drops the incoming message and counts it (the original
+   // behavior).
+   PolicyDrop OverflowPolicy = iota
+   // PolicyBlock blocks the caller until the channel has room.
+   PolicyBlock
+   // PolicyDropOldest evicts one queued message to make room for the new
+   // one, trading history for recency.
+   PolicyDropOldest
+)
+
+// StatsSnapshot is a point-in-time snapshot of LogServer activity, as
+// returned by the package-level Stats function.
+type StatsSnapshot struct {
+   Enqueued   uint64
+   Dropped    uint64
+   Bytes      uint64
+   Rotations  uint64
+   QueueDepth []int
+}
+
+// Stats returns a snapshot of the default server's counters and current
+// per-worker queue depths.
+func Stats() StatsSnapshot {
+   s := getServer()
+
+   depths := make([]int, s.numWorkers)
+   for i := range depths {
+       depths[i] = len(s.logChans[i])
+   }
+
+   return StatsSnapshot{
+       Enqueued:   s.enqueued.Load(),
+       Dropped:    s.dropped.Load(),
+       Bytes:      s.bytesOut.Load(),
+       Rotations:  s.rotations.Load(),
+       QueueDepth: depths,
+   }
+}
+
+// enqueue routes msg to a worker channel according to s.overflowPolicy.
+func (s *LogServer) enqueue(msg logMessage) {
+   ch := s.logChans[pickWorker(s.numWorkers)]
+
+   switch s.overflowPolicy {
+   case PolicyBlock:
+       ch <- msg
+       s.enqueued.Add(1)
+
+   case PolicyDropOldest:
+       select {
+       case ch <- msg:
+           s.enqueued.Add(1)
+           return
+       default:
+       }
+       select {
+       case <-ch:
+           s.dropped.Add(1)
+       default:
+       }
+       select {
+       case ch <- msg:
+           s.enqueued.Add(1)
+       default:
+           s.dropped.Add(1)
+           fmt.Fprintf(os.Stderr, "Warning: Log channel full, message dropped after evicting oldest: %s\n", msg.text)
+       }
+
+   default: // PolicyDrop
+       select {
+       case ch <- msg:
+           s.enqueued.Add(1)
+       default:
+           s.dropped.Add(1)
+           fmt.Fprintf(os.Stderr, "Warning: Log channel full, message dropped: %s\n", msg.text)
+       }
+   }
+}
@@ -0,0 +1,71 @@
+package logging
+
+import "testing"
+
+func newTestServer(numWorkers, chanBuf int, policy OverflowPolicy) *LogServer {
+   s := &LogServer{
+       numWorkers:     numWorkers,
+       logChans:       make([]chan logMessage, numWorkers),
+       overflowPolicy: policy,
+   }
+   for i := range s.logChans {
+       s.logChans[i] = make(chan logMessage, chanBuf)
+   }
+   return s
+}
+
+func TestEnqueuePolicyDropCountsOverflow(t *testing.T) {
+   s := newTestServer(1, 1, PolicyDrop)
+
+   s.enqueue(logMessage{text: "first"})
+   s.enqueue(logMessage{text: "second"}) // channel already full, should drop
+
+   if got := s.enqueued.Load(); got != 1 {
+       t.Errorf("Enqueued = %d, want 1", got)
+   }
+   if got := s.dropped.Load(); got != 1 {
+       t.Errorf("Dropped = %d, want 1", got)
+   }
+   if got := (<-s.logChans[0]).text; got != "first" {
+       t.Errorf("surviving message = %q, want %q", got, "first")
+   }
+}
+
+func TestEnqueuePolicyDropOldestEvictsOldest(t *testing.T) {
+   s := newTestServer(1, 1, PolicyDropOldest)
+
+   s.enqueue(logMessage{text: "first"})
+   s.enqueue(logMessage{text: "second"}) // should evict "first" to make room
+
+   if got := s.enqueued.Load(); got != 2 {
+       t.Errorf("Enqueued = %d, want 2", got)
+   }
+   if got := s.dropped.Load(); got != 1 {
+       t.Errorf("Dropped = %d, want 1", got)
+   }
+   if got := (<-s.logChans[0]).text; got != "second" {
+       t.Errorf("surviving message = %q, want %q (oldest should have been evicted)", got, "second")
+   }
+}
+
+func TestEnqueuePolicyBlockDoesNotDrop(t *testing.T) {
+   s := newTestServer(1, 2, PolicyBlock)
+
+   s.enqueue(logMessage{text: "first"})
+   s.enqueue(logMessage{text: "second"})
+
+   if got := s.enqueued.Load(); got != 2 {
+       t.Errorf("Enqueued = %d, want 2", got)
+   }
+   if got := s.dropped.Load(); got != 0 {
+       t.Errorf("Dropped = %d, want 0", got)
+   }
+}
+
+func TestPickWorkerInRange(t *testing.T) {
+   for i := 0; i < 100; i++ {
+       if w := pickWorker(4); w < 0 || w >= 4 {
+           t.Fatalf("pickWorker(4) = %d, want [0,4)", w)
+       }
+   }
+}
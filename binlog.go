@@ -0,0 +1,389 @@
+package logging
+
+import (
+   "encoding/binary"
+   "encoding/json"
+   "errors"
+   "fmt"
+   "os"
+   "path/filepath"
+   "strconv"
+   "strings"
+   "sync"
+   "time"
+)
+
+const (
+   binlogPrefix    = "anthropic-binlog"
+   binlogIndexName = binlogPrefix + ".index"
+   recordHeaderLen = 12 // CreateTimeUnix uint32 + BatchID uint32 + PayloadLen uint32
+)
+
+// Entry is a single record recovered from the binlog by ReplayBinlog.
+type Entry struct {
+   Timestamp time.Time
+   Level     LogLevel
+   File      string
+   Line      int
+   Text      string
+   Fields    map[string]any
+}
+
+// FormatEntry renders a replayed Entry through the same formatter used for
+// live records, so a reconstructed text log or regenerated JSON stream
+// matches what would have been written at the time.
+func FormatEntry(e Entry, format Format) []byte {
+   return formatMessage(logMessage{
+       timestamp: e.Timestamp,
+       level:     e.Level,
+       file:      e.File,
+       line:      e.Line,
+       text:      e.Text,
+       fields:    e.Fields,
+   }, format)
+}
+
+// binlogWriter is an append-only, segmented write-ahead log. Every
+// logMessage processed by a worker is appended here (independent of any
+// Sink) before it reaches a formatter, so a crash before a batch is
+// flushed to its sinks can still be replayed from disk.
+type binlogWriter struct {
+   mu          sync.Mutex
+   dir         string
+   segmentSize int64
+   segmentSeq  int
+   file        *os.File
+   fileSize    int64
+}
+
+func newBinlogWriter(dir string, segmentSize int64) (*binlogWriter, error) {
+   if err := os.MkdirAll(dir, 0755); err != nil {
+       return nil, fmt.Errorf("logging: failed to create binlog directory: %w", err)
+   }
+
+   w := &binlogWriter{dir: dir, segmentSize: segmentSize}
+   if err := w.recover(); err != nil {
+       return nil, fmt.Errorf("logging: binlog recovery failed: %w", err)
+   }
+   if w.segmentSeq == 0 {
+       if err := w.rollLocked(); err != nil {
+           return nil, err
+       }
+   } else if err := w.reopenCurrentSegment(); err != nil {
+       return nil, err
+   }
+   return w, nil
+}
+
+func segmentName(seq int) string {
+   return fmt.Sprintf("%s.%05d", binlogPrefix, seq)
+}
+
+func parseSegmentSeq(name string) (int, error) {
+   parts := strings.Split(name, ".")
+   return strconv.Atoi(parts[len(parts)-1])
+}
+
+func (w *binlogWriter) indexPath() string {
+   return filepath.Join(w.dir, binlogIndexName)
+}
+
+func (w *binlogWriter) loadIndex() ([]string, error) {
+   data, err := os.ReadFile(w.indexPath())
+   if errors.Is(err, os.ErrNotExist) {
+       return nil, nil
+   }
+   if err != nil {
+       return nil, err
+   }
+
+   var segments []string
+   for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+       if line != "" {
+           segments = append(segments, line)
+       }
+   }
+   return segments, nil
+}
+
+func (w *binlogWriter) appendIndex(name string) error {
+   f, err := os.OpenFile(w.indexPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+   if err != nil {
+       return err
+   }
+   defer f.Close()
+
+   _, err = f.WriteString(name + "\n")
+   return err
+}
+
+// recover truncates a partially-written trailing record on the most recent
+// segment, left behind by a crash mid-Append, and records which segment
+// writing should resume on.
+func (w *binlogWriter) recover() error {
+   segments, err := w.loadIndex()
+   if err != nil {
+       return err
+   }
+   if len(segments) == 0 {
+       return nil
+   }
+
+   last := segments[len(segments)-1]
+   seq, err := parseSegmentSeq(last)
+   if err != nil {
+       return err
+   }
+
+   path := filepath.Join(w.dir, last)
+   validSize, err := scanValidRecords(path)
+   if err != nil {
+       return err
+   }
+   if err := os.Truncate(path, validSize); err != nil {
+       return err
+   }
+
+   w.segmentSeq = seq
+   return nil
+}
+
+// scanValidRecords walks the length-prefixed records in path and returns
+// the byte offset of the end of the last complete one.
+func scanValidRecords(path string) (int64, error) {
+   data, err := os.ReadFile(path)
+   if errors.Is(err, os.ErrNotExist) {
+       return 0, nil
+   }
+   if err != nil {
+       return 0, err
+   }
+
+   var offset int64
+   for offset+recordHeaderLen <= int64(len(data)) {
+       payloadLen := binary.BigEndian.Uint32(data[offset+8 : offset+12])
+       recordEnd := offset + recordHeaderLen + int64(payloadLen)
+       if recordEnd > int64(len(data)) {
+           break
+       }
+       offset = recordEnd
+   }
+   return offset, nil
+}
+
+func (w *binlogWriter) reopenCurrentSegment() error {
+   path := filepath.Join(w.dir, segmentName(w.segmentSeq))
+   info, err := os.Stat(path)
+   if err != nil {
+       return err
+   }
+
+   f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+   if err != nil {
+       return err
+   }
+
+   w.file = f
+   w.fileSize = info.Size()
+   return nil
+}
+
+func (w *binlogWriter) rollLocked() error {
+   if w.file != nil {
+       w.file.Close()
+   }
+
+   w.segmentSeq++
+   name := segmentName(w.segmentSeq)
+
+   f, err := os.Create(filepath.Join(w.dir, name))
+   if err != nil {
+       return fmt.Errorf("logging: failed to create binlog segment: %w", err)
+   }
+   if err := w.appendIndex(name); err != nil {
+       return fmt.Errorf("logging: failed to update binlog index: %w", err)
+   }
+
+   w.file = f
+   w.fileSize = 0
+   return nil
+}
+
+// Append writes msg as a length-prefixed record, tagged with batchID (the
+// flush batch it will belong to once its sinks catch up).
+func (w *binlogWriter) Append(msg logMessage, batchID uint32) error {
+   w.mu.Lock()
+   defer w.mu.Unlock()
+
+   payload := encodeBinlogPayload(msg)
+   record := make([]byte, recordHeaderLen+len(payload))
+   binary.BigEndian.PutUint32(record[0:4], uint32(time.Now().Unix()))
+   binary.BigEndian.PutUint32(record[4:8], batchID)
+   binary.BigEndian.PutUint32(record[8:12], uint32(len(payload)))
+   copy(record[recordHeaderLen:], payload)
+
+   n, err := w.file.Write(record)
+   if err != nil {
+       return err
+   }
+
+   w.fileSize += int64(n)
+   if w.fileSize >= w.segmentSize {
+       return w.rollLocked()
+   }
+   return nil
+}
+
+func encodeBinlogPayload(msg logMessage) []byte {
+   fileBytes := []byte(msg.file)
+   textBytes := []byte(msg.text)
+   fieldsBytes, err := json.Marshal(msg.fields)
+   if err != nil {
+       fieldsBytes = []byte("null")
+   }
+
+   buf := make([]byte, 0, 4+4+8+2+len(fileBytes)+4+len(textBytes)+4+len(fieldsBytes))
+   var b4 [4]byte
+   var b8 [8]byte
+
+   binary.BigEndian.PutUint32(b4[:], uint32(msg.level))
+   buf = append(buf, b4[:]...)
+
+   binary.BigEndian.PutUint32(b4[:], uint32(msg.line))
+   buf = append(buf, b4[:]...)
+
+   binary.BigEndian.PutUint64(b8[:], uint64(msg.timestamp.UnixNano()))
+   buf = append(buf, b8[:]...)
+
+   binary.BigEndian.PutUint16(b4[:2], uint16(len(fileBytes)))
+   buf = append(buf, b4[:2]...)
+   buf = append(buf, fileBytes...)
+
+   binary.BigEndian.PutUint32(b4[:], uint32(len(textBytes)))
+   buf = append(buf, b4[:]...)
+   buf = append(buf, textBytes...)
+
+   binary.BigEndian.PutUint32(b4[:], uint32(len(fieldsBytes)))
+   buf = append(buf, b4[:]...)
+   buf = append(buf, fieldsBytes...)
+
+   return buf
+}
+
+func decodeBinlogPayload(data []byte) (logMessage, error) {
+   if len(data) < 4+4+8+2 {
+       return logMessage{}, errors.New("logging: truncated binlog payload")
+   }
+
+   level := LogLevel(binary.BigEndian.Uint32(data[0:4]))
+   line := int(binary.BigEndian.Uint32(data[4:8]))
+   tsNanos := int64(binary.BigEndian.Uint64(data[8:16]))
+
+   off := 16
+   fileLen := int(binary.BigEndian.Uint16(data[off : off+2]))
+   off += 2
+   if off+fileLen > len(data) {
+       return logMessage{}, errors.New("logging: truncated binlog payload")
+   }
+   file := string(data[off : off+fileLen])
+   off += fileLen
+
+   if off+4 > len(data) {
+       return logMessage{}, errors.New("logging: truncated binlog payload")
+   }
+   textLen := int(binary.BigEndian.Uint32(data[off : off+4]))
+   off += 4
+   if off+textLen > len(data) {
+       return logMessage{}, errors.New("logging: truncated binlog payload")
+   }
+   text := string(data[off : off+textLen])
+   off += textLen
+
+   if off+4 > len(data) {
+       return logMessage{}, errors.New("logging: truncated binlog payload")
+   }
+   fieldsLen := int(binary.BigEndian.Uint32(data[off : off+4]))
+   off += 4
+   if off+fieldsLen > len(data) {
+       return logMessage{}, errors.New("logging: truncated binlog payload")
+   }
+   var fields map[string]any
+   if fieldsLen > 0 {
+       if err := json.Unmarshal(data[off:off+fieldsLen], &fields); err != nil {
+           return logMessage{}, fmt.Errorf("logging: corrupt binlog fields: %w", err)
+       }
+   }
+
+   return logMessage{
+       timestamp: time.Unix(0, tsNanos),
+       level:     level,
+       file:      file,
+       line:      line,
+       text:      text,
+       fields:    fields,
+   }, nil
+}
+
+// replay streams every record at or after since through fn, in segment
+// and on-disk order.
+func (w *binlogWriter) replay(since time.Time, fn func(Entry) error) error {
+   w.mu.Lock()
+   segments, err := w.loadIndex()
+   w.mu.Unlock()
+   if err != nil {
+       return err
+   }
+
+   for _, seg := range segments {
+       data, err := os.ReadFile(filepath.Join(w.dir, seg))
+       if err != nil {
+           return err
+       }
+
+       var offset int64
+       for offset+recordHeaderLen <= int64(len(data)) {
+           payloadLen := binary.BigEndian.Uint32(data[offset+8 : offset+12])
+           recordEnd := offset + recordHeaderLen + int64(payloadLen)
+           if recordEnd > int64(len(data)) {
+               break
+           }
+
+           msg, err := decodeBinlogPayload(data[offset+recordHeaderLen : recordEnd])
+           if err != nil {
+               return err
+           }
+           offset = recordEnd
+
+           if msg.timestamp.Before(since) {
+               continue
+           }
+           if err := fn(Entry{
+               Timestamp: msg.timestamp,
+               Level:     msg.level,
+               File:      msg.file,
+               Line:      msg.line,
+               Text:      msg.text,
+               Fields:    msg.fields,
+           }); err != nil {
+               return err
+           }
+       }
+   }
+   return nil
+}
+
+// ReplayBinlog streams every binlog record at or after since through fn, in
+// the order they were written. It returns an error if the default server
+// was not configured with Config.Binlog enabled.
+func ReplayBinlog(since time.Time, fn func(Entry) error) error {
+   s := getServer()
+
+   s.mu.RLock()
+   bw := s.binlog
+   s.mu.RUnlock()
+
+   if bw == nil {
+       return errors.New("logging: binlog is not enabled")
+   }
+   return bw.replay(since, fn)
+}
@@ -0,0 +1,13 @@
+// Package calltest exists solely so the logging package's tests can
+// exercise getCallerInfo and SetPackageLevel against a real distinct
+// caller package, rather than
+// against the logging package's own call sites. It takes the log function
+// as a parameter instead of importing the logging package directly, since
+// an internal test in package logging importing a helper that itself
+// imports logging would be a cycle.
+package calltest
+
+// CallDebug invokes debugFn from this package's own call site.
+func CallDebug(debugFn func(string), text string) {
+   debugFn(text)
+}
@@ -2,6 +2,7 @@ package logging
 
 import (
    "bytes"
+   "encoding/json"
    "fmt"
    "os"
    "path/filepath"
@@ -12,7 +13,7 @@ import (
 )
 
 const (
-   defaultBufferSize   = 20000    
+   defaultBufferSize   = 20000
    defaultNumWorkers   = 8
    defaultBatchSize    = 10000
    defaultFlushIntervalMs = 50
@@ -20,6 +21,7 @@ const (
    maxFiles          = 10
    logDirName        = "logs"
    logFilePrefix     = "anthropic-debug"
+   defaultBinlogSegmentSize = 64 << 20 // 64MB
 )
 
 type LogLevel int32
@@ -27,15 +29,22 @@ type LogLevel int32
 const (
    DEBUG LogLevel = iota
    INFO
-   WARN  
+   WARN
    ERROR
    FATAL
 )
 
+// Format selects how records are rendered before being handed to a sink.
+type Format int32
+
+const (
+   FormatText Format = iota
+   FormatJSON
+)
+
 type LogServer struct {
    mu              sync.RWMutex
-   currentFile     *os.File
-   fileSize        int64
+   sinks           []Sink
    enabled         atomic.Bool
    logChans        []chan logMessage
    buffers         []*bytes.Buffer
@@ -44,8 +53,16 @@ type LogServer struct {
    flushInterval   time.Duration
    logLevel        LogLevel
    logDir          string
-   rotateSize      int64
-   maxFiles        int
+   format          Format
+   binlog          *binlogWriter
+   batchSeq        atomic.Uint32
+   overflowPolicy  OverflowPolicy
+   enqueued        atomic.Uint64
+   dropped         atomic.Uint64
+   bytesOut        atomic.Uint64
+   rotations       atomic.Uint64
+   packageLevels   atomic.Value // []packageLevel
+   sampler         *sampler
 }
 
 type logMessage struct {
@@ -54,6 +71,16 @@ type logMessage struct {
    file      string
    line      int
    text      string
+   fields    map[string]any
+}
+
+// jsonRecord is the on-the-wire shape emitted when Config.Format is FormatJSON.
+type jsonRecord struct {
+   Time   string         `json:"timestamp"`
+   Level  string         `json:"level"`
+   Caller string         `json:"caller"`
+   Msg    string         `json:"msg"`
+   Fields map[string]any `json:"fields,omitempty"`
 }
 
 var (
@@ -69,6 +96,38 @@ type Config struct {
    LogDir        string
    RotateSize    int64
    MaxFiles      int
+   Format        Format
+   // Sinks overrides the default file sink. When empty, NewLogServer falls
+   // back to a single file sink under LogDir using RotateSize/MaxFiles and
+   // the daily/compression knobs below.
+   Sinks         []Sink
+   // Daily rotates the default file sink once per calendar day in addition
+   // to RotateSize.
+   Daily         bool
+   // MaxDays prunes .log/.log.gz files older than this many days. 0 disables
+   // age-based pruning.
+   MaxDays       int
+   // Compress gzips rotated files in the background instead of leaving
+   // them as plain .log files.
+   Compress      bool
+   // MaxArchives bounds the number of .log.gz files independently of
+   // MaxFiles, which only bounds uncompressed .log files. 0 disables.
+   MaxArchives   int
+   // Binlog enables a write-ahead binlog: every message is appended to a
+   // segment file before it reaches a formatter, so it can be replayed
+   // with ReplayBinlog after a crash that happened before a sink flush.
+   Binlog        bool
+   // BinlogDir is where binlog segments and their index live. Defaults to
+   // LogDir/binlog when empty.
+   BinlogDir     string
+   // BinlogSegmentSize is the byte size at which a binlog segment rolls.
+   BinlogSegmentSize int64
+   // OverflowPolicy controls what happens when a worker's channel is full.
+   // Defaults to PolicyDrop.
+   OverflowPolicy OverflowPolicy
+   // Sample rate-limits noisy call sites per level without globally
+   // silencing that level, e.g. {DEBUG: {Every: 100}}.
+   Sample map[LogLevel]SampleRule
 }
 
 func DefaultConfig() Config {
@@ -80,6 +139,7 @@ func DefaultConfig() Config {
        LogDir:        logDirName,
        RotateSize:    maxFileSize,
        MaxFiles:      maxFiles,
+       Format:        FormatText,
    }
 }
 
@@ -91,7 +151,14 @@ func getServer() *LogServer {
 }
 
 func NewLogServer(config Config) *LogServer {
+   sinks := config.Sinks
+   if len(sinks) == 0 {
+       sinks = []Sink{newFileSink(config.LogDir, config.RotateSize, config.MaxFiles,
+           config.Daily, config.MaxDays, config.Compress, config.MaxArchives)}
+   }
+
    s := &LogServer{
+       sinks:         sinks,
        logChans:      make([]chan logMessage, config.NumWorkers),
        buffers:       make([]*bytes.Buffer, config.NumWorkers),
        numWorkers:    config.NumWorkers,
@@ -99,8 +166,26 @@ func NewLogServer(config Config) *LogServer {
        flushInterval: config.FlushInterval,
        logLevel:      config.LogLevel,
        logDir:        config.LogDir,
-       rotateSize:    config.RotateSize,
-       maxFiles:      config.MaxFiles,
+       format:        config.Format,
+       overflowPolicy: config.OverflowPolicy,
+       sampler:       newSampler(config.Sample, defaultSamplerLRUSize),
+   }
+
+   if config.Binlog {
+       dir := config.BinlogDir
+       if dir == "" {
+           dir = filepath.Join(config.LogDir, "binlog")
+       }
+       segmentSize := config.BinlogSegmentSize
+       if segmentSize <= 0 {
+           segmentSize = defaultBinlogSegmentSize
+       }
+       bw, err := newBinlogWriter(dir, segmentSize)
+       if err != nil {
+           fmt.Fprintf(os.Stderr, "Error initializing binlog: %v\n", err)
+       } else {
+           s.binlog = bw
+       }
    }
 
    for i := 0; i < config.NumWorkers; i++ {
@@ -123,16 +208,17 @@ func (s *LogServer) processWorker(id int) {
        }
 
        if msg.level < s.logLevel {
-           continue  
+           continue
        }
 
-       buffer.WriteString(fmt.Sprintf("[%s] [%s] %s:%d %s\n",
-           msg.timestamp.Format("2006-01-02 15:04:05.000"),
-           levelToString(msg.level),
-           msg.file,
-           msg.line,
-           msg.text))
-       
+       if s.binlog != nil {
+           if err := s.binlog.Append(msg, s.batchSeq.Load()); err != nil {
+               fmt.Fprintf(os.Stderr, "Error appending to binlog: %v\n", err)
+           }
+       }
+
+       buffer.Write(formatMessage(msg, s.format))
+
        count++
        if count >= s.batchSize {
            s.flush(id)
@@ -161,64 +247,40 @@ func (s *LogServer) flush(id int) {
    s.mu.Lock()
    defer s.mu.Unlock()
 
-   if s.currentFile == nil {
-       return
-   }
-
    data := s.buffers[id].Bytes()
-   n, err := s.currentFile.Write(data)
-   if err != nil {
-       fmt.Fprintf(os.Stderr, "Error writing to log file: %v\n", err)
-       return
+   s.bytesOut.Add(uint64(len(data)))
+   for _, sink := range s.sinks {
+       if err := sink.Write(data); err != nil {
+           fmt.Fprintf(os.Stderr, "Error writing to sink: %v\n", err)
+       }
    }
-
-   s.fileSize += int64(n)
    s.buffers[id].Reset()
-
-   if s.fileSize >= s.rotateSize {
-       s.rotate()
-   }
+   s.batchSeq.Add(1)
 }
 
 func (s *LogServer) flushAll() {
    for i := 0; i < s.numWorkers; i++ {
        s.flush(i)
    }
-   if s.currentFile != nil {
-       s.currentFile.Sync()
+   for _, sink := range s.sinks {
+       if err := sink.Sync(); err != nil {
+           fmt.Fprintf(os.Stderr, "Error syncing sink: %v\n", err)
+       }
    }
 }
 
 func (s *LogServer) rotate() {
-   if s.currentFile != nil {
-       s.currentFile.Close()
-   }
-
-   // Delete old files if we have too many
-   files, err := filepath.Glob(filepath.Join(s.logDir, fmt.Sprintf("%s-*.log", logFilePrefix)))
-   if err == nil && len(files) >= s.maxFiles {
-       for i := 0; i < len(files)-s.maxFiles+1; i++ {
-           os.Remove(files[i])
+   s.rotations.Add(1)
+   for _, sink := range s.sinks {
+       if err := sink.Rotate(); err != nil {
+           fmt.Fprintf(os.Stderr, "Error rotating sink: %v\n", err)
        }
    }
-
-   // Create new file
-   timestamp := time.Now().Format("20060102-150405")
-   newPath := filepath.Join(s.logDir, fmt.Sprintf("%s-%s.log", logFilePrefix, timestamp))
-   
-   file, err := os.Create(newPath)
-   if err != nil {
-       fmt.Fprintf(os.Stderr, "Error creating new log file: %v\n", err)
-       return
-   }
-
-   s.currentFile = file
-   s.fileSize = 0
 }
 
 func EnableLogging() error {
    s := getServer()
-   
+
    if err := os.MkdirAll(s.logDir, 0755); err != nil {
        return fmt.Errorf("failed to create log directory: %w", err)
    }
@@ -230,23 +292,24 @@ func EnableLogging() error {
        return nil
    }
 
-   s.rotate() // Create initial file
+   s.rotate() // Create initial files for any sinks that need one
    s.enabled.Store(true)
-   
+
    return nil
 }
 
 func DisableLogging() {
    s := getServer()
    s.enabled.Store(false)
-   
+
    s.mu.Lock()
    defer s.mu.Unlock()
 
    s.flushAll()
-   if s.currentFile != nil {
-       s.currentFile.Close()
-       s.currentFile = nil
+   for _, sink := range s.sinks {
+       if err := sink.Close(); err != nil {
+           fmt.Fprintf(os.Stderr, "Error closing sink: %v\n", err)
+       }
    }
 }
 
@@ -275,45 +338,162 @@ func levelToString(level LogLevel) string {
    }
 }
 
+// levelFromString is the inverse of levelToString, used by sinks that parse
+// a level back out of an already-formatted line.
+func levelFromString(s string) (LogLevel, bool) {
+   switch s {
+   case "DEBUG":
+       return DEBUG, true
+   case "INFO":
+       return INFO, true
+   case "WARN":
+       return WARN, true
+   case "ERROR":
+       return ERROR, true
+   case "FATAL":
+       return FATAL, true
+   default:
+       return 0, false
+   }
+}
+
+// formatMessage renders msg according to format, returning a complete line
+// (including trailing newline) ready to be written to a sink buffer.
+func formatMessage(msg logMessage, format Format) []byte {
+   switch format {
+   case FormatJSON:
+       rec := jsonRecord{
+           Time:   msg.timestamp.Format("2006-01-02 15:04:05.000"),
+           Level:  levelToString(msg.level),
+           Caller: fmt.Sprintf("%s:%d", msg.file, msg.line),
+           Msg:    msg.text,
+           Fields: msg.fields,
+       }
+       data, err := json.Marshal(rec)
+       if err != nil {
+           fmt.Fprintf(os.Stderr, "Error marshaling log record: %v\n", err)
+           return nil
+       }
+       return append(data, '\n')
+   default:
+       return []byte(fmt.Sprintf("[%s] [%s] %s:%d %s\n",
+           msg.timestamp.Format("2006-01-02 15:04:05.000"),
+           levelToString(msg.level),
+           msg.file,
+           msg.line,
+           msg.text))
+   }
+}
+
+// getCallerInfo returns the file and line of the real call site. The chain
+// is user code -> Debug/Info/Logger.Debug -> logWithLevelFields ->
+// getCallerInfo -> runtime.Caller, and Caller's skip=N ascends one frame
+// further than its own body for each increment, so skip=3 is needed to
+// land on user code: 0 would resolve within this function, 1 to
+// logWithLevelFields, 2 to the Debug/Info/Logger method itself (a wrapper
+// defined in this package, not the real caller).
 func getCallerInfo() (string, int) {
-   _, file, line, ok := runtime.Caller(2)
+   _, file, line, ok := runtime.Caller(3)
    if !ok {
        return "unknown", 0
    }
    return filepath.Base(file), line
 }
 
-func logWithLevel(level LogLevel, text string) {
+func logWithLevelFields(level LogLevel, text string, fields map[string]any) {
    s := getServer()
-   if !s.enabled.Load() || level < s.logLevel {
+   if !s.enabled.Load() {
        return
    }
 
    file, line := getCallerInfo()
+
+   threshold := s.logLevel
+   if pl, ok := s.packageLevel(getCallerPackage()); ok {
+       threshold = pl
+   }
+   if level < threshold {
+       return
+   }
+
+   if !s.sampler.allow(level, file, line) {
+       return
+   }
+
+   // Clone fields before it crosses the channel boundary: the caller may
+   // reuse or mutate the map after this call returns, racing with
+   // processWorker's formatMessage on another goroutine.
    msg := logMessage{
        timestamp: time.Now(),
        level:     level,
        file:      file,
        line:      line,
        text:      text,
+       fields:    cloneFields(fields),
    }
 
-   workerID := time.Now().UnixNano() % int64(s.numWorkers)
-   select {
-   case s.logChans[workerID] <- msg:
-   default:
-       fmt.Fprintf(os.Stderr, "Warning: Log channel full, message dropped: %s\n", text)
+   s.enqueue(msg)
+}
+
+func Debug(text string) { logWithLevelFields(DEBUG, text, nil) }
+func Info(text string)  { logWithLevelFields(INFO, text, nil) }
+func Warn(text string)  { logWithLevelFields(WARN, text, nil) }
+func Error(text string) { logWithLevelFields(ERROR, text, nil) }
+func Fatal(text string) {
+   logWithLevelFields(FATAL, text, nil)
+   os.Exit(1)
+}
+
+// DebugFields logs msg at DEBUG level with the given structured fields attached.
+// Fields are only rendered when Config.Format is FormatJSON; in FormatText mode
+// they are accepted but dropped, matching the existing text line shape.
+func DebugFields(msg string, fields map[string]any) { logWithLevelFields(DEBUG, msg, fields) }
+func InfoFields(msg string, fields map[string]any)  { logWithLevelFields(INFO, msg, fields) }
+func WarnFields(msg string, fields map[string]any)  { logWithLevelFields(WARN, msg, fields) }
+func ErrorFields(msg string, fields map[string]any) { logWithLevelFields(ERROR, msg, fields) }
+func FatalFields(msg string, fields map[string]any) {
+   logWithLevelFields(FATAL, msg, fields)
+   os.Exit(1)
+}
+
+// Logger carries a set of fields that are attached to every record it emits.
+// Obtain one via With and pass it down a call chain that needs consistent
+// structured context (request id, user id, ...).
+type Logger struct {
+   fields map[string]any
+}
+
+// With returns a Logger that attaches fields to every record it logs. Use
+// the Logger's own With method to add fields on top of these.
+func With(fields map[string]any) Logger {
+   return Logger{fields: cloneFields(fields)}
+}
+
+// With returns a new Logger with fields merged on top of l's existing fields.
+func (l Logger) With(fields map[string]any) Logger {
+   merged := cloneFields(l.fields)
+   for k, v := range fields {
+       merged[k] = v
    }
+   return Logger{fields: merged}
 }
 
-func Debug(text string) { logWithLevel(DEBUG, text) }
-func Info(text string)  { logWithLevel(INFO, text) }
-func Warn(text string)  { logWithLevel(WARN, text) }
-func Error(text string) { logWithLevel(ERROR, text) }
-func Fatal(text string) { 
-   logWithLevel(FATAL, text)
+func (l Logger) Debug(text string) { logWithLevelFields(DEBUG, text, l.fields) }
+func (l Logger) Info(text string)  { logWithLevelFields(INFO, text, l.fields) }
+func (l Logger) Warn(text string)  { logWithLevelFields(WARN, text, l.fields) }
+func (l Logger) Error(text string) { logWithLevelFields(ERROR, text, l.fields) }
+func (l Logger) Fatal(text string) {
+   logWithLevelFields(FATAL, text, l.fields)
    os.Exit(1)
 }
 
+func cloneFields(fields map[string]any) map[string]any {
+   clone := make(map[string]any, len(fields))
+   for k, v := range fields {
+       clone[k] = v
+   }
+   return clone
+}
+
 // Backward compatibility
 func WriteLogs(text string) { Info(text) }
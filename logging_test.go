@@ -0,0 +1,103 @@
+package logging
+
+import (
+   "strings"
+   "testing"
+   "time"
+
+   "github.com/rdhillbb/logging/internal/calltest"
+)
+
+func TestFormatMessageJSONIncludesFields(t *testing.T) {
+   msg := logMessage{
+       timestamp: time.Unix(1700000000, 0).UTC(),
+       level:     INFO,
+       file:      "main.go",
+       line:      7,
+       text:      "request handled",
+       fields:    map[string]any{"request_id": "abc123"},
+   }
+
+   data := formatMessage(msg, FormatJSON)
+   if !strings.Contains(string(data), `"request_id":"abc123"`) {
+       t.Errorf("formatMessage(FormatJSON) = %s, want it to include fields", data)
+   }
+   if !strings.Contains(string(data), `"level":"INFO"`) {
+       t.Errorf("formatMessage(FormatJSON) = %s, want level INFO", data)
+   }
+}
+
+func TestFormatMessageJSONOmitsEmptyFields(t *testing.T) {
+   msg := logMessage{timestamp: time.Unix(1700000000, 0).UTC(), level: DEBUG, file: "main.go", line: 1, text: "no fields"}
+
+   data := formatMessage(msg, FormatJSON)
+   if strings.Contains(string(data), `"fields"`) {
+       t.Errorf("formatMessage(FormatJSON) = %s, want no \"fields\" key when none were attached", data)
+   }
+}
+
+func TestLoggerWithMergesAndOverwritesFields(t *testing.T) {
+   base := With(map[string]any{"service": "api", "env": "prod"})
+   extended := base.With(map[string]any{"env": "staging", "request_id": "abc123"})
+
+   if base.fields["env"] != "prod" {
+       t.Errorf("base.fields[env] = %v, want prod (With must not mutate the receiver)", base.fields["env"])
+   }
+   if extended.fields["service"] != "api" {
+       t.Errorf("extended.fields[service] = %v, want api (inherited from base)", extended.fields["service"])
+   }
+   if extended.fields["env"] != "staging" {
+       t.Errorf("extended.fields[env] = %v, want staging (later With call must overwrite)", extended.fields["env"])
+   }
+   if extended.fields["request_id"] != "abc123" {
+       t.Errorf("extended.fields[request_id] = %v, want abc123", extended.fields["request_id"])
+   }
+}
+
+// TestGetCallerInfoReportsRealCallSite guards against getCallerInfo
+// resolving to the logging package's own Debug/Info/Logger wrapper instead
+// of the actual call site, which would make the "caller" field useless for
+// log aggregation.
+func TestGetCallerInfoReportsRealCallSite(t *testing.T) {
+   sink := resetServer(t, Config{
+       NumWorkers:    1,
+       BatchSize:     1,
+       FlushInterval: time.Hour,
+       LogLevel:      DEBUG,
+       Format:        FormatJSON,
+   })
+
+   calltest.CallDebug(Debug, "marker-caller-site")
+   waitFor(t, func() bool { return strings.Contains(sink.String(), "marker-caller-site") })
+
+   if strings.Contains(sink.String(), "logging.go") {
+       t.Fatalf("caller attribute points at logging.go (the Debug wrapper), not the real call site; sink=%q", sink.String())
+   }
+   if !strings.Contains(sink.String(), "calltest.go") {
+       t.Fatalf("expected caller attribute to mention calltest.go, sink=%q", sink.String())
+   }
+}
+
+// TestDebugFieldsClonesFieldsMap reproduces the data race between a caller
+// reusing/mutating its fields map across calls (a normal pattern) and
+// processWorker's formatMessage marshaling the same map concurrently on
+// another goroutine. Run with `go test -race` to verify; without cloning
+// in logWithLevelFields this fails with a DATA RACE between
+// runtime.mapassign_faststr and encoding/json's map read.
+func TestDebugFieldsClonesFieldsMap(t *testing.T) {
+   sink := resetServer(t, Config{
+       NumWorkers:    1,
+       BatchSize:     1,
+       FlushInterval: time.Hour,
+       LogLevel:      DEBUG,
+       Format:        FormatJSON,
+   })
+
+   fields := map[string]any{"n": float64(0)}
+   for i := 0; i < 500; i++ {
+       fields["n"] = float64(i)
+       DebugFields("tick", fields)
+   }
+
+   waitFor(t, func() bool { return strings.Contains(sink.String(), `"tick"`) })
+}
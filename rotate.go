@@ -0,0 +1,140 @@
+package logging
+
+import (
+   "compress/gzip"
+   "fmt"
+   "io"
+   "os"
+   "path/filepath"
+   "sort"
+   "strings"
+   "time"
+)
+
+// archiveEntry is a retired log file discovered on disk, tagged with the
+// timestamp embedded in its name so retention can be age/order based
+// instead of relying on directory listing order.
+type archiveEntry struct {
+   path string
+   ts   time.Time
+}
+
+func sameDay(a, b time.Time) bool {
+   ay, am, ad := a.Date()
+   by, bm, bd := b.Date()
+   return ay == by && am == bm && ad == bd
+}
+
+// parseArchiveTimestamp recovers the rotation timestamp embedded in a
+// "<prefix>-20060102-150405.log[.gz]" file name.
+func parseArchiveTimestamp(path string) (time.Time, error) {
+   base := filepath.Base(path)
+   base = strings.TrimPrefix(base, logFilePrefix+"-")
+   base = strings.TrimSuffix(base, ".log.gz")
+   base = strings.TrimSuffix(base, ".log")
+   return time.Parse("20060102-150405", base)
+}
+
+// listArchives globs dir for pattern and returns matches sorted oldest
+// first by their embedded timestamp. Entries whose name doesn't parse are
+// skipped rather than mis-sorted.
+func listArchives(dir, pattern string) []archiveEntry {
+   matches, err := filepath.Glob(filepath.Join(dir, pattern))
+   if err != nil {
+       return nil
+   }
+
+   entries := make([]archiveEntry, 0, len(matches))
+   for _, m := range matches {
+       ts, err := parseArchiveTimestamp(m)
+       if err != nil {
+           continue
+       }
+       entries = append(entries, archiveEntry{path: m, ts: ts})
+   }
+
+   sort.Slice(entries, func(i, j int) bool { return entries[i].ts.Before(entries[j].ts) })
+   return entries
+}
+
+// pruneOlderThan removes entries whose timestamp is before cutoff and
+// returns the ones that survive, still oldest first.
+func pruneOlderThan(entries []archiveEntry, cutoff time.Time) []archiveEntry {
+   kept := entries[:0]
+   for _, e := range entries {
+       if e.ts.Before(cutoff) {
+           os.Remove(e.path)
+           continue
+       }
+       kept = append(kept, e)
+   }
+   return kept
+}
+
+// pruneLocked applies MaxDays/MaxFiles/MaxArchives retention to the
+// uncompressed .log files and compressed .log.gz archives in f.dir. It
+// must be called with f.mu held.
+func (f *fileSink) pruneLocked() {
+   logs := listArchives(f.dir, fmt.Sprintf("%s-*.log", logFilePrefix))
+   archives := listArchives(f.dir, fmt.Sprintf("%s-*.log.gz", logFilePrefix))
+
+   if f.maxDays > 0 {
+       cutoff := time.Now().AddDate(0, 0, -f.maxDays)
+       logs = pruneOlderThan(logs, cutoff)
+       archives = pruneOlderThan(archives, cutoff)
+   }
+
+   if f.maxFiles > 0 && len(logs) > f.maxFiles {
+       for _, e := range logs[:len(logs)-f.maxFiles] {
+           os.Remove(e.path)
+       }
+   }
+
+   if f.maxArchives > 0 && len(archives) > f.maxArchives {
+       for _, e := range archives[:len(archives)-f.maxArchives] {
+           os.Remove(e.path)
+       }
+   }
+}
+
+// compressLoop runs on its own goroutine so gzip work never stalls a
+// processWorker flushing through Write. When compressCh is full,
+// rotateLocked compresses on a separate goroutine instead.
+func (f *fileSink) compressLoop() {
+   for path := range f.compressCh {
+       if err := compressFile(path); err != nil {
+           fmt.Fprintf(os.Stderr, "Error compressing log file %s: %v\n", path, err)
+           continue
+       }
+       f.mu.Lock()
+       f.pruneLocked()
+       f.mu.Unlock()
+   }
+}
+
+// compressFile gzips path to path+".gz" and removes the original on success.
+func compressFile(path string) error {
+   src, err := os.Open(path)
+   if err != nil {
+       return err
+   }
+   defer src.Close()
+
+   dstPath := path + ".gz"
+   dst, err := os.Create(dstPath)
+   if err != nil {
+       return err
+   }
+   defer dst.Close()
+
+   gw := gzip.NewWriter(dst)
+   if _, err := io.Copy(gw, src); err != nil {
+       gw.Close()
+       return err
+   }
+   if err := gw.Close(); err != nil {
+       return err
+   }
+
+   return os.Remove(path)
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+   "fmt"
+   "os"
+   "path/filepath"
+   "testing"
+   "time"
+)
+
+// TestPruneLockedRemovesOldestFirst guards against retention ordering by
+// directory-listing order instead of the timestamp embedded in each
+// archive's name, which can delete the wrong files depending on on-disk
+// order rather than age.
+func TestPruneLockedRemovesOldestFirst(t *testing.T) {
+   dir := t.TempDir()
+
+   mkLog := func(ts string) string {
+       path := filepath.Join(dir, fmt.Sprintf("%s-%s.log", logFilePrefix, ts))
+       if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+           t.Fatalf("WriteFile(%s): %v", path, err)
+       }
+       return path
+   }
+
+   oldest := mkLog("20240101-000000")
+   middle := mkLog("20240102-000000")
+   newest := mkLog("20240103-000000")
+
+   f := &fileSink{dir: dir, maxFiles: 2}
+   f.pruneLocked()
+
+   if _, err := os.Stat(oldest); !os.IsNotExist(err) {
+       t.Errorf("expected oldest archive %s to be pruned, stat err=%v", oldest, err)
+   }
+   for _, path := range []string{middle, newest} {
+       if _, err := os.Stat(path); err != nil {
+           t.Errorf("expected %s to survive pruning: %v", path, err)
+       }
+   }
+}
+
+// TestPruneOlderThanKeepsNewer verifies MaxDays-based pruning removes an
+// entry before the cutoff from disk and keeps the one after it.
+func TestPruneOlderThanKeepsNewer(t *testing.T) {
+   dir := t.TempDir()
+
+   older := filepath.Join(dir, fmt.Sprintf("%s-20200101-000000.log", logFilePrefix))
+   newer := filepath.Join(dir, fmt.Sprintf("%s-20300101-000000.log", logFilePrefix))
+   for _, path := range []string{older, newer} {
+       if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+           t.Fatalf("WriteFile(%s): %v", path, err)
+       }
+   }
+
+   cutoff, err := time.Parse("20060102-150405", "20250101-000000")
+   if err != nil {
+       t.Fatalf("time.Parse: %v", err)
+   }
+
+   entries := listArchives(dir, fmt.Sprintf("%s-*.log", logFilePrefix))
+   kept := pruneOlderThan(entries, cutoff)
+
+   if len(kept) != 1 || filepath.Base(kept[0].path) != filepath.Base(newer) {
+       t.Errorf("pruneOlderThan kept %v, want only %s", kept, newer)
+   }
+   if _, err := os.Stat(older); !os.IsNotExist(err) {
+       t.Errorf("expected %s to be removed, stat err=%v", older, err)
+   }
+}
@@ -0,0 +1,216 @@
+package logging
+
+import (
+   "container/list"
+   "runtime"
+   "sort"
+   "strings"
+   "sync"
+   "time"
+)
+
+const defaultSamplerLRUSize = 4096
+
+// packageLevel is one entry in the compiled prefix matcher maintained by
+// SetPackageLevel and consulted by logWithLevelFields; entries are kept
+// sorted longest-prefix-first so the most specific match wins.
+type packageLevel struct {
+   prefix string
+   level  LogLevel
+}
+
+// SetPackageLevel overrides the effective log level for every call site
+// whose full import path starts with pkg (e.g. "github.com/acme/widget").
+// The most specific (longest) matching prefix wins over SetLogLevel's
+// global level.
+func SetPackageLevel(pkg string, level LogLevel) {
+   s := getServer()
+
+   s.mu.Lock()
+   defer s.mu.Unlock()
+
+   existing, _ := s.packageLevels.Load().([]packageLevel)
+   updated := make([]packageLevel, 0, len(existing)+1)
+   found := false
+   for _, pl := range existing {
+       if pl.prefix == pkg {
+           pl.level = level
+           found = true
+       }
+       updated = append(updated, pl)
+   }
+   if !found {
+       updated = append(updated, packageLevel{prefix: pkg, level: level})
+   }
+
+   sort.Slice(updated, func(i, j int) bool {
+       return len(updated[i].prefix) > len(updated[j].prefix)
+   })
+   s.packageLevels.Store(updated)
+}
+
+// packageLevel returns the most specific override for pkg, if any.
+func (s *LogServer) packageLevel(pkg string) (LogLevel, bool) {
+   levels, _ := s.packageLevels.Load().([]packageLevel)
+   for _, pl := range levels {
+       if strings.HasPrefix(pkg, pl.prefix) {
+           return pl.level, true
+       }
+   }
+   return 0, false
+}
+
+// getCallerPackage returns the full import path of the function that
+// called the package-level Debug/Info/.../Logger method, using
+// runtime.CallersFrames rather than filepath.Base so multiple packages
+// sharing a file basename (e.g. "client.go") don't collide.
+//
+// The call chain is user code -> Debug/Info/Logger.Debug ->
+// logWithLevelFields -> getCallerPackage -> runtime.Callers. skip=4 ascends
+// past runtime.Callers itself, getCallerPackage, logWithLevelFields, and
+// the Debug/Info/Logger method, landing on the actual caller; this is the
+// same frame getCallerInfo's runtime.Caller(3) reaches, since
+// Callers' skip=N is equivalent to Caller's skip=N-1.
+func getCallerPackage() string {
+   pcs := make([]uintptr, 1)
+   n := runtime.Callers(4, pcs)
+   if n == 0 {
+       return ""
+   }
+   frame, _ := runtime.CallersFrames(pcs[:n]).Next()
+   return packageFromFunction(frame.Function)
+}
+
+// packageFromFunction trims a fully qualified function name such as
+// "github.com/acme/widget.(*Client).Do" down to its package import path,
+// "github.com/acme/widget".
+func packageFromFunction(fn string) string {
+   if idx := strings.LastIndex(fn, "/"); idx >= 0 {
+       rest := fn[idx+1:]
+       if dot := strings.Index(rest, "."); dot >= 0 {
+           return fn[:idx+1+dot]
+       }
+       return fn
+   }
+   if dot := strings.Index(fn, "."); dot >= 0 {
+       return fn[:dot]
+   }
+   return fn
+}
+
+// SampleRule rate-limits a single LogLevel at each distinct call site. Set
+// Every for simple counter-based sampling (1 in N), or Burst/PerSecond for
+// a token-bucket that allows bursts of Burst records before falling back
+// to PerSecond steady state.
+type SampleRule struct {
+   Every     int
+   Burst     int
+   PerSecond int
+}
+
+type sampleKey struct {
+   file string
+   line int
+}
+
+type sampleCounter struct {
+   count      uint64
+   tokens     float64
+   lastRefill time.Time
+}
+
+func (c *sampleCounter) allow(rule SampleRule) bool {
+   if rule.Every > 0 {
+       c.count++
+       return (c.count-1)%uint64(rule.Every) == 0
+   }
+
+   if rule.Burst > 0 || rule.PerSecond > 0 {
+       burst := float64(rule.Burst)
+       if burst == 0 {
+           burst = float64(rule.PerSecond)
+       }
+
+       now := time.Now()
+       if c.lastRefill.IsZero() {
+           c.tokens = burst
+       } else {
+           c.tokens += now.Sub(c.lastRefill).Seconds() * float64(rule.PerSecond)
+           if c.tokens > burst {
+               c.tokens = burst
+           }
+       }
+       c.lastRefill = now
+
+       if c.tokens >= 1 {
+           c.tokens--
+           return true
+       }
+       return false
+   }
+
+   return true
+}
+
+// sampler rate-limits noisy (file, line) call sites per LogLevel, bounding
+// memory with an LRU so a program with many transient call sites (e.g.
+// generated code, plugins) can't grow it unbounded.
+type sampler struct {
+   mu         sync.Mutex
+   rules      map[LogLevel]SampleRule
+   lru        *list.List
+   index      map[sampleKey]*list.Element
+   maxEntries int
+}
+
+type sampleLRUEntry struct {
+   key     sampleKey
+   counter *sampleCounter
+}
+
+func newSampler(rules map[LogLevel]SampleRule, maxEntries int) *sampler {
+   return &sampler{
+       rules:      rules,
+       lru:        list.New(),
+       index:      make(map[sampleKey]*list.Element),
+       maxEntries: maxEntries,
+   }
+}
+
+func (s *sampler) allow(level LogLevel, file string, line int) bool {
+   if s == nil || len(s.rules) == 0 {
+       return true
+   }
+   rule, ok := s.rules[level]
+   if !ok {
+       return true
+   }
+
+   s.mu.Lock()
+   defer s.mu.Unlock()
+
+   key := sampleKey{file: file, line: line}
+   var counter *sampleCounter
+   if el, found := s.index[key]; found {
+       counter = el.Value.(*sampleLRUEntry).counter
+       s.lru.MoveToFront(el)
+   } else {
+       counter = &sampleCounter{}
+       el := s.lru.PushFront(&sampleLRUEntry{key: key, counter: counter})
+       s.index[key] = el
+       s.evictLocked()
+   }
+
+   return counter.allow(rule)
+}
+
+func (s *sampler) evictLocked() {
+   for len(s.index) > s.maxEntries {
+       oldest := s.lru.Back()
+       if oldest == nil {
+           return
+       }
+       s.lru.Remove(oldest)
+       delete(s.index, oldest.Value.(*sampleLRUEntry).key)
+   }
+}
@@ -0,0 +1,95 @@
+package logging
+
+import (
+   "strings"
+   "sync"
+   "testing"
+   "time"
+
+   "github.com/rdhillbb/logging/internal/calltest"
+)
+
+// memSink collects every flushed batch in memory for test assertions.
+type memSink struct {
+   mu   sync.Mutex
+   data []byte
+}
+
+func (m *memSink) Write(data []byte) error {
+   m.mu.Lock()
+   defer m.mu.Unlock()
+   m.data = append(m.data, data...)
+   return nil
+}
+func (m *memSink) Sync() error   { return nil }
+func (m *memSink) Rotate() error { return nil }
+func (m *memSink) Close() error  { return nil }
+
+func (m *memSink) String() string {
+   m.mu.Lock()
+   defer m.mu.Unlock()
+   return string(m.data)
+}
+
+// resetServer installs a fresh LogServer as the package-level singleton so
+// a test can control Config and inspect what reaches a sink, then tears it
+// down afterwards.
+func resetServer(t *testing.T, cfg Config) *memSink {
+   t.Helper()
+
+   sink := &memSink{}
+   cfg.Sinks = []Sink{sink}
+   s := NewLogServer(cfg)
+   s.enabled.Store(true)
+
+   server = s
+   once = sync.Once{}
+   once.Do(func() {}) // consume Once so getServer() returns s, not a fresh default server
+
+   t.Cleanup(func() {
+       s.enabled.Store(false)
+       for _, ch := range s.logChans {
+           close(ch)
+       }
+   })
+   return sink
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+   t.Helper()
+   deadline := time.Now().Add(time.Second)
+   for time.Now().Before(deadline) {
+       if cond() {
+           return
+       }
+       time.Sleep(2 * time.Millisecond)
+   }
+   t.Fatalf("condition not met before deadline")
+}
+
+// TestSetPackageLevelMatchesRealCallerPackage guards against
+// getCallerPackage resolving to this package's own import path (the
+// package Debug/Logger.Debug are defined in) instead of the real caller's,
+// which would make SetPackageLevel a no-op for every external caller.
+func TestSetPackageLevelMatchesRealCallerPackage(t *testing.T) {
+   sink := resetServer(t, Config{
+       NumWorkers:    1,
+       BatchSize:     1,
+       FlushInterval: time.Hour,
+       LogLevel:      DEBUG,
+       Format:        FormatJSON,
+   })
+
+   const calltestPkg = "github.com/rdhillbb/logging/internal/calltest"
+   SetPackageLevel(calltestPkg, FATAL)
+   t.Cleanup(func() { SetPackageLevel(calltestPkg, DEBUG) })
+
+   Debug("marker-own-package")
+   waitFor(t, func() bool { return strings.Contains(sink.String(), "marker-own-package") })
+
+   calltest.CallDebug(Debug, "marker-other-package")
+   time.Sleep(50 * time.Millisecond) // give the worker a chance to (wrongly) let it through
+   if strings.Contains(sink.String(), "marker-other-package") {
+       t.Fatalf("SetPackageLevel(%q, FATAL) did not suppress calltest.CallDebug; sink=%q", calltestPkg, sink.String())
+   }
+}
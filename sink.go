@@ -0,0 +1,259 @@
+package logging
+
+import (
+   "bytes"
+   "encoding/json"
+   "fmt"
+   "io"
+   "os"
+   "path/filepath"
+   "sync"
+   "time"
+)
+
+// Sink is a destination for formatted log batches. LogServer fans every
+// flushed batch out to each registered Sink; a Sink is free to buffer,
+// rotate, or drop as it sees fit, but Write should not block the caller
+// for longer than the sink's own I/O requires.
+type Sink interface {
+   Write(data []byte) error
+   Sync() error
+   Rotate() error
+   Close() error
+}
+
+// fileSink is the default Sink and reproduces the library's original
+// file-rotation behavior: rotate on size, keep at most maxFiles files. It
+// optionally adds daily rotation and background gzip compression of
+// retired files; see rotate.go.
+type fileSink struct {
+   mu          sync.Mutex
+   dir         string
+   rotateSize  int64
+   maxFiles    int
+   daily       bool
+   maxDays     int
+   compress    bool
+   maxArchives int
+   currentFile *os.File
+   fileSize    int64
+   openedAt    time.Time
+   compressCh  chan string
+}
+
+func newFileSink(dir string, rotateSize int64, maxFiles int, daily bool, maxDays int, compress bool, maxArchives int) *fileSink {
+   f := &fileSink{
+       dir:         dir,
+       rotateSize:  rotateSize,
+       maxFiles:    maxFiles,
+       daily:       daily,
+       maxDays:     maxDays,
+       compress:    compress,
+       maxArchives: maxArchives,
+   }
+   if compress {
+       f.compressCh = make(chan string, 16)
+       go f.compressLoop()
+   }
+   return f
+}
+
+func (f *fileSink) Write(data []byte) error {
+   f.mu.Lock()
+   defer f.mu.Unlock()
+
+   if f.daily && f.currentFile != nil && !sameDay(f.openedAt, time.Now()) {
+       if err := f.rotateLocked(); err != nil {
+           return err
+       }
+   }
+
+   if f.currentFile == nil {
+       return nil
+   }
+
+   n, err := f.currentFile.Write(data)
+   if err != nil {
+       return err
+   }
+
+   f.fileSize += int64(n)
+   if f.fileSize >= f.rotateSize {
+       return f.rotateLocked()
+   }
+   return nil
+}
+
+func (f *fileSink) Sync() error {
+   f.mu.Lock()
+   defer f.mu.Unlock()
+
+   if f.currentFile == nil {
+       return nil
+   }
+   return f.currentFile.Sync()
+}
+
+func (f *fileSink) Rotate() error {
+   f.mu.Lock()
+   defer f.mu.Unlock()
+   return f.rotateLocked()
+}
+
+func (f *fileSink) rotateLocked() error {
+   var retiredPath string
+   if f.currentFile != nil {
+       retiredPath = f.currentFile.Name()
+       f.currentFile.Close()
+   }
+
+   f.pruneLocked()
+
+   // Create new file
+   now := time.Now()
+   timestamp := now.Format("20060102-150405")
+   newPath := filepath.Join(f.dir, fmt.Sprintf("%s-%s.log", logFilePrefix, timestamp))
+
+   file, err := os.Create(newPath)
+   if err != nil {
+       return fmt.Errorf("error creating new log file: %w", err)
+   }
+
+   f.currentFile = file
+   f.fileSize = 0
+   f.openedAt = now
+
+   // Prune with the new file in place so it counts toward maxFiles.
+   f.pruneLocked()
+
+   if retiredPath != "" && f.compress {
+       select {
+       case f.compressCh <- retiredPath:
+       default:
+           // Compressor is backed up; compress on a separate goroutine
+           // rather than leave the file uncompressed indefinitely.
+           go func(path string) {
+               if err := compressFile(path); err != nil {
+                   fmt.Fprintf(os.Stderr, "Error compressing log file %s: %v\n", path, err)
+               }
+           }(retiredPath)
+       }
+   }
+   return nil
+}
+
+func (f *fileSink) Close() error {
+   f.mu.Lock()
+   defer f.mu.Unlock()
+
+   if f.currentFile == nil {
+       return nil
+   }
+   err := f.currentFile.Close()
+   f.currentFile = nil
+   return err
+}
+
+// consoleSink writes batches straight to an io.Writer (typically os.Stdout
+// or os.Stderr), optionally coloring each line by the level formatMessage
+// recorded for it.
+type consoleSink struct {
+   w      io.Writer
+   color  bool
+   format Format
+}
+
+// NewConsoleSink returns a Sink that writes to w. format must match the
+// Config.Format the server was built with. When color is true, lines are
+// wrapped in an ANSI color escape chosen by the record's parsed level.
+func NewConsoleSink(w io.Writer, color bool, format Format) Sink {
+   return &consoleSink{w: w, color: color, format: format}
+}
+
+var levelColors = map[string]string{
+   "FATAL": "\x1b[35m",
+   "ERROR": "\x1b[31m",
+   "WARN":  "\x1b[33m",
+   "INFO":  "\x1b[32m",
+   "DEBUG": "\x1b[36m",
+}
+
+const colorReset = "\x1b[0m"
+
+func (c *consoleSink) Write(data []byte) error {
+   if !c.color {
+       _, err := c.w.Write(data)
+       return err
+   }
+
+   lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+   for _, line := range lines {
+       if len(line) == 0 {
+           continue
+       }
+       color := ""
+       if level, ok := parseLineLevel(line, c.format); ok {
+           color = levelColors[levelToString(level)]
+       }
+       if color == "" {
+           if _, err := fmt.Fprintf(c.w, "%s\n", line); err != nil {
+               return err
+           }
+           continue
+       }
+       if _, err := fmt.Fprintf(c.w, "%s%s%s\n", color, line, colorReset); err != nil {
+           return err
+       }
+   }
+   return nil
+}
+
+// parseLineLevel recovers the LogLevel formatMessage recorded for line,
+// without scanning the free-form message text for a level-looking
+// substring (a message like Info("ERROR code received: 500") must not be
+// mistaken for an ERROR record).
+func parseLineLevel(line []byte, format Format) (LogLevel, bool) {
+   if format == FormatJSON {
+       var rec struct {
+           Level string `json:"level"`
+       }
+       if err := json.Unmarshal(line, &rec); err != nil {
+           return 0, false
+       }
+       return levelFromString(rec.Level)
+   }
+   return levelFromString(textLevelToken(line))
+}
+
+// textLevelToken returns the second bracketed token in a
+// "[timestamp] [LEVEL] file:line msg" line, i.e. the level field at its
+// known position, rather than a substring match against the whole line.
+func textLevelToken(line []byte) string {
+   first := bytes.IndexByte(line, ']')
+   if first < 0 {
+       return ""
+   }
+   rest := line[first+1:]
+
+   start := bytes.IndexByte(rest, '[')
+   if start < 0 {
+       return ""
+   }
+   rest = rest[start+1:]
+
+   end := bytes.IndexByte(rest, ']')
+   if end < 0 {
+       return ""
+   }
+   return string(rest[:end])
+}
+
+func (c *consoleSink) Sync() error {
+   if f, ok := c.w.(*os.File); ok {
+       return f.Sync()
+   }
+   return nil
+}
+
+func (c *consoleSink) Rotate() error { return nil }
+func (c *consoleSink) Close() error  { return nil }
@@ -0,0 +1,46 @@
+//go:build kafka
+
+// Package logging's Kafka sink is only built when the kafka tag is passed
+// (`go build -tags kafka ./...`), since github.com/segmentio/kafka-go
+// requires Go >= 1.23 while the rest of this module targets go.mod's 1.21.
+// Building with the tag also requires adding the dependency to go.mod,
+// e.g. `go get github.com/segmentio/kafka-go@v0.4.51 && go mod tidy`, and
+// bumping the go.mod `go` directive to 1.23 or later.
+package logging
+
+import (
+   "context"
+   "time"
+
+   kafka "github.com/segmentio/kafka-go"
+)
+
+// kafkaSink produces each flushed batch as a single Kafka message. Produces
+// are async: the batch boundary already imposed by processWorker/flush is
+// reused as the produce boundary, so no extra buffering is added here.
+type kafkaSink struct {
+   writer *kafka.Writer
+}
+
+// NewKafkaSink returns a Sink that publishes flushed batches to topic on
+// brokers. Produces are asynchronous and best-effort, matching the
+// fire-and-forget nature of the other sinks.
+func NewKafkaSink(brokers []string, topic string) Sink {
+   return &kafkaSink{
+       writer: &kafka.Writer{
+           Addr:         kafka.TCP(brokers...),
+           Topic:        topic,
+           Balancer:     &kafka.LeastBytes{},
+           Async:        true,
+           BatchTimeout: 10 * time.Millisecond,
+       },
+   }
+}
+
+func (k *kafkaSink) Write(data []byte) error {
+   return k.writer.WriteMessages(context.Background(), kafka.Message{Value: data})
+}
+
+func (k *kafkaSink) Sync() error   { return nil }
+func (k *kafkaSink) Rotate() error { return nil }
+func (k *kafkaSink) Close() error  { return k.writer.Close() }
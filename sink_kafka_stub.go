@@ -0,0 +1,26 @@
+//go:build !kafka
+
+package logging
+
+import "fmt"
+
+// kafkaUnavailableSink stands in for kafkaSink when this package is built
+// without the kafka tag, so the rest of the module doesn't need
+// github.com/segmentio/kafka-go (and the newer Go toolchain it requires)
+// just to build.
+type kafkaUnavailableSink struct{}
+
+// NewKafkaSink returns a Sink that publishes flushed batches to topic on
+// brokers. Build this package with `-tags kafka` for it to actually reach
+// Kafka; without the tag, the returned Sink's Write always fails.
+func NewKafkaSink(brokers []string, topic string) Sink {
+   return &kafkaUnavailableSink{}
+}
+
+func (k *kafkaUnavailableSink) Write(data []byte) error {
+   return fmt.Errorf("logging: kafka sink requires building with -tags kafka")
+}
+
+func (k *kafkaUnavailableSink) Sync() error   { return nil }
+func (k *kafkaUnavailableSink) Rotate() error { return nil }
+func (k *kafkaUnavailableSink) Close() error  { return nil }
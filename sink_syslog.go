@@ -0,0 +1,94 @@
+//go:build !windows
+
+package logging
+
+import (
+   "bytes"
+   "fmt"
+   "log/syslog"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+   "kern":     syslog.LOG_KERN,
+   "user":     syslog.LOG_USER,
+   "mail":     syslog.LOG_MAIL,
+   "daemon":   syslog.LOG_DAEMON,
+   "auth":     syslog.LOG_AUTH,
+   "syslog":   syslog.LOG_SYSLOG,
+   "local0":   syslog.LOG_LOCAL0,
+   "local1":   syslog.LOG_LOCAL1,
+   "local2":   syslog.LOG_LOCAL2,
+   "local3":   syslog.LOG_LOCAL3,
+   "local4":   syslog.LOG_LOCAL4,
+   "local5":   syslog.LOG_LOCAL5,
+   "local6":   syslog.LOG_LOCAL6,
+   "local7":   syslog.LOG_LOCAL7,
+}
+
+type syslogSink struct {
+   w      *syslog.Writer
+   format Format
+}
+
+// NewSyslogSink connects to the local syslog daemon under the given
+// facility (e.g. "local0", "daemon", "user") and tag. format must match
+// the Config.Format the server was built with, so each line's level can
+// be parsed back out and reported to syslog at its own severity rather
+// than the single severity a *syslog.Writer is otherwise fixed at.
+func NewSyslogSink(facility, tag string, format Format) (Sink, error) {
+   prio, ok := syslogFacilities[facility]
+   if !ok {
+       return nil, fmt.Errorf("logging: unknown syslog facility %q", facility)
+   }
+
+   w, err := syslog.New(prio, tag)
+   if err != nil {
+       return nil, fmt.Errorf("logging: failed to connect to syslog: %w", err)
+   }
+   return &syslogSink{w: w, format: format}, nil
+}
+
+// Write reports each line in data at its own syslog severity. A plain
+// w.Write would fix every record at the priority passed to syslog.New
+// (LOG_EMERG, since severity is left at its zero value there), so DEBUG
+// through FATAL records all read as emergencies; instead each line's
+// level is parsed back out and routed to the matching *syslog.Writer
+// method, which overrides severity per-write while keeping the facility.
+func (s *syslogSink) Write(data []byte) error {
+   lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+   for _, line := range lines {
+       if len(line) == 0 {
+           continue
+       }
+
+       level, ok := parseLineLevel(line, s.format)
+       if !ok {
+           level = INFO
+       }
+
+       msg := string(line)
+       var err error
+       switch level {
+       case DEBUG:
+           err = s.w.Debug(msg)
+       case INFO:
+           err = s.w.Info(msg)
+       case WARN:
+           err = s.w.Warning(msg)
+       case ERROR:
+           err = s.w.Err(msg)
+       case FATAL:
+           err = s.w.Emerg(msg)
+       default:
+           err = s.w.Info(msg)
+       }
+       if err != nil {
+           return err
+       }
+   }
+   return nil
+}
+
+func (s *syslogSink) Sync() error   { return nil }
+func (s *syslogSink) Rotate() error { return nil }
+func (s *syslogSink) Close() error  { return s.w.Close() }
@@ -0,0 +1,11 @@
+//go:build windows
+
+package logging
+
+import "fmt"
+
+// NewSyslogSink is not supported on windows; log/syslog has no windows
+// implementation.
+func NewSyslogSink(facility, tag string, format Format) (Sink, error) {
+   return nil, fmt.Errorf("logging: syslog sink is not supported on windows")
+}
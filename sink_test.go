@@ -0,0 +1,27 @@
+package logging
+
+import "testing"
+
+func TestParseLineLevelIgnoresMessageText(t *testing.T) {
+   line := []byte("[2026-07-29 00:00:00.000] [INFO] main.go:42 ERROR code received: 500")
+
+   level, ok := parseLineLevel(line, FormatText)
+   if !ok {
+       t.Fatalf("parseLineLevel returned ok=false for %q", line)
+   }
+   if level != INFO {
+       t.Errorf("parseLineLevel(%q) = %v, want INFO (message text containing \"ERROR\" must not override the real level)", line, level)
+   }
+}
+
+func TestParseLineLevelJSON(t *testing.T) {
+   line := []byte(`{"timestamp":"2026-07-29 00:00:00.000","level":"WARN","caller":"main.go:42","msg":"ERROR-looking text"}`)
+
+   level, ok := parseLineLevel(line, FormatJSON)
+   if !ok {
+       t.Fatalf("parseLineLevel returned ok=false for %q", line)
+   }
+   if level != WARN {
+       t.Errorf("parseLineLevel(%q) = %v, want WARN", line, level)
+   }
+}